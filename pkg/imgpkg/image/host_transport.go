@@ -0,0 +1,158 @@
+package image
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// hostAwareTransport is an http.RoundTripper that picks a per-host
+// http.Transport (so each host's tls.Config is applied without giving up
+// connection pooling) and, for hosts that configure mirrors, rewrites the
+// outbound request to try each mirror endpoint in turn while preserving
+// the original Host header so auth scopes still match the image
+// reference.
+type hostAwareTransport struct {
+	defaultTransport *http.Transport
+	hostTransport    map[string]*http.Transport
+	hostConfig       HostConfigs
+}
+
+func newHostAwareTransport(base *http.Transport, defaultTLS *tls.Config, configs HostConfigs) (*hostAwareTransport, error) {
+	hostTransport := map[string]*http.Transport{}
+
+	for host, cfg := range configs {
+		tlsConfig, err := tlsConfigFor(defaultTLS, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("Building TLS config for host '%s': %s", host, err)
+		}
+
+		tran := base.Clone()
+		tran.TLSClientConfig = tlsConfig
+		hostTransport[host] = tran
+	}
+
+	return &hostAwareTransport{defaultTransport: base, hostTransport: hostTransport, hostConfig: configs}, nil
+}
+
+func tlsConfigFor(defaultTLS *tls.Config, cfg HostConfig) (*tls.Config, error) {
+	pool := defaultTLS.RootCAs
+	if len(cfg.CACertPaths) > 0 {
+		pool = x509.NewCertPool()
+		for _, path := range cfg.CACertPaths {
+			certs, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("Reading CA certificates from '%s': %s", path, err)
+			}
+			if ok := pool.AppendCertsFromPEM(certs); !ok {
+				return nil, fmt.Errorf("Adding CA certificates from '%s': failed", path)
+			}
+		}
+	}
+
+	certs := defaultTLS.Certificates
+	if len(cfg.ClientCertPath) > 0 || len(cfg.ClientKeyPath) > 0 {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertPath, cfg.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("Loading client certificate/key pair from '%s'/'%s': %s",
+				cfg.ClientCertPath, cfg.ClientKeyPath, err)
+		}
+		certs = []tls.Certificate{cert}
+	}
+
+	return &tls.Config{
+		RootCAs:            pool,
+		Certificates:       certs,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}, nil
+}
+
+func (t *hostAwareTransport) transportFor(host string) *http.Transport {
+	if tran, found := t.hostTransport[host]; found {
+		return tran
+	}
+	return t.defaultTransport
+}
+
+func (t *hostAwareTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// req.URL.Host carries the port (where req.URL.Hostname() would strip
+	// it), and HostConfigs is keyed the same way callers name hosts in
+	// certs.d directories, hosts.toml paths and registries.yaml entries
+	// (e.g. "localhost:5000"), so the lookup must match on it too.
+	host := req.URL.Host
+	tran := t.transportFor(host)
+
+	cfg, found := t.hostConfig[host]
+	if !found || len(cfg.Mirrors) == 0 {
+		return tran.RoundTrip(req)
+	}
+
+	var lastResp *http.Response
+	var lastErr error
+
+	for idx, mirror := range cfg.Mirrors {
+		attempt, err := requestForMirror(req, mirror)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		resp, err := tran.RoundTrip(attempt)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		isLast := idx == len(cfg.Mirrors)-1
+		if resp.StatusCode >= 400 && !isLast {
+			resp.Body.Close()
+			lastResp, lastErr = resp, nil
+			continue
+		}
+
+		return resp, nil
+	}
+
+	if lastResp != nil {
+		return lastResp, nil
+	}
+	return nil, lastErr
+}
+
+// requestForMirror clones req to target mirror instead of its original
+// host, preserving the original Host header for auth scopes. mirror may be
+// a bare host[:port] or scheme-prefixed (e.g. "http://mirror:5000"); when
+// it carries no scheme, req's original scheme is kept.
+func requestForMirror(req *http.Request, mirror string) (*http.Request, error) {
+	scheme, host := req.URL.Scheme, mirror
+
+	switch {
+	case strings.HasPrefix(mirror, "https://"):
+		scheme, host = "https", strings.TrimPrefix(mirror, "https://")
+	case strings.HasPrefix(mirror, "http://"):
+		scheme, host = "http", strings.TrimPrefix(mirror, "http://")
+	}
+	host = strings.TrimSuffix(host, "/")
+
+	if len(host) == 0 {
+		return nil, fmt.Errorf("Mirror endpoint '%s' has no host", mirror)
+	}
+
+	attempt := req.Clone(req.Context())
+	attempt.Host = req.URL.Host
+	attempt.URL.Scheme = scheme
+	attempt.URL.Host = host
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("Rewinding request body for mirror '%s': %s", mirror, err)
+		}
+		attempt.Body = body
+	}
+
+	return attempt, nil
+}
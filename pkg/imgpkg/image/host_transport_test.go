@@ -0,0 +1,53 @@
+package image
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostAwareTransportRoundTripHostPort(t *testing.T) {
+	stale := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer stale.Close()
+
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "registry.example.com:5000" {
+			t.Errorf("Host header = %q, want %q", r.Host, "registry.example.com:5000")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer good.Close()
+
+	configs := HostConfigs{
+		// Keyed with the port, matching how LoadDockerCertsDir/
+		// LoadContainerdHostsTOML/LoadRegistriesYAML name hosts.
+		"registry.example.com:5000": {
+			Mirrors: []string{stale.URL, good.URL},
+		},
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+
+	tran, err := newHostAwareTransport(base, &tls.Config{}, configs)
+	if err != nil {
+		t.Fatalf("newHostAwareTransport: %s", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://registry.example.com:5000/v2/", nil)
+	if err != nil {
+		t.Fatalf("Building request: %s", err)
+	}
+
+	resp, err := tran.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode = %d, want %d (expected failover past the stale mirror)", resp.StatusCode, http.StatusOK)
+	}
+}
@@ -0,0 +1,157 @@
+package image
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	regtoml "github.com/pelletier/go-toml"
+)
+
+// HostConfig captures the TLS material and endpoint mirrors that apply to
+// requests against a single registry host, as configured via Docker's
+// certs.d layout or containerd's hosts.toml files.
+type HostConfig struct {
+	CACertPaths        []string
+	ClientCertPath     string
+	ClientKeyPath      string
+	InsecureSkipVerify bool
+
+	// Mirrors are alternate endpoints (host[:port], optionally with a
+	// scheme) to dial instead of the original host. The original host is
+	// still sent as the HTTP Host header so auth scopes stay correct.
+	Mirrors []string
+}
+
+// HostConfigs maps a registry hostname (as it appears in image references)
+// to the HostConfig that should be used when talking to it.
+type HostConfigs map[string]HostConfig
+
+// LoadDockerCertsDir reads Docker's `/etc/docker/certs.d/<host>/{ca.crt,*.cert,*.key}`
+// layout and returns a HostConfig per host directory found under dir.
+func LoadDockerCertsDir(dir string) (HostConfigs, error) {
+	configs := HostConfigs{}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Reading certs.d directory '%s': %s", dir, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		host := entry.Name()
+		hostDir := filepath.Join(dir, host)
+
+		files, err := ioutil.ReadDir(hostDir)
+		if err != nil {
+			return nil, fmt.Errorf("Reading certs.d host directory '%s': %s", hostDir, err)
+		}
+
+		cfg := HostConfig{}
+
+		for _, f := range files {
+			path := filepath.Join(hostDir, f.Name())
+
+			switch {
+			case f.Name() == "ca.crt":
+				cfg.CACertPaths = append(cfg.CACertPaths, path)
+			case strings.HasSuffix(f.Name(), ".cert"):
+				cfg.ClientCertPath = path
+			case strings.HasSuffix(f.Name(), ".key"):
+				cfg.ClientKeyPath = path
+			}
+		}
+
+		configs[host] = cfg
+	}
+
+	return configs, nil
+}
+
+// LoadContainerdHostsTOML reads a single containerd-style
+// `<hostsDir>/<registryHost>/hosts.toml` file and returns the HostConfig
+// to use for registryHost: its Mirrors are the `[host."..."]` endpoints in
+// the order they appear in the file (containerd tries them in file order),
+// followed by `server` as the final fallback to the original registry.
+// Of containerd's hosts.toml schema
+// (https://github.com/containerd/containerd/blob/main/docs/hosts.md) only
+// `server` and each endpoint's `ca`, `client` and `skip_verify` are read.
+// CA and client certificate paths are taken from the first endpoint that
+// declares them; `skip_verify = true` on any endpoint applies to the host.
+func LoadContainerdHostsTOML(registryHost string, path string) (HostConfig, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return HostConfig{}, fmt.Errorf("Reading hosts.toml '%s' for host '%s': %s", path, registryHost, err)
+	}
+
+	tree, err := regtoml.LoadBytes(raw)
+	if err != nil {
+		return HostConfig{}, fmt.Errorf("Parsing hosts.toml '%s' for host '%s': %s", path, registryHost, err)
+	}
+
+	cfg := HostConfig{}
+
+	if hostTree, ok := tree.Get("host").(*regtoml.Tree); ok {
+		// hostTree.Keys() comes back in map order, so endpoints are sorted
+		// by where they appear in the file, the order containerd tries them
+		// in. Endpoint names contain dots, so they are looked up as a single
+		// path element rather than through Get's dot-separated keys.
+		endpoints := hostTree.Keys()
+		sort.Slice(endpoints, func(a, b int) bool {
+			posA := hostTree.GetPositionPath([]string{endpoints[a]})
+			posB := hostTree.GetPositionPath([]string{endpoints[b]})
+			if posA.Line != posB.Line {
+				return posA.Line < posB.Line
+			}
+			return posA.Col < posB.Col
+		})
+
+		for _, endpoint := range endpoints {
+			sub, ok := hostTree.GetPath([]string{endpoint}).(*regtoml.Tree)
+			if !ok {
+				continue
+			}
+
+			cfg.Mirrors = append(cfg.Mirrors, endpoint)
+
+			if len(cfg.CACertPaths) == 0 {
+				switch ca := sub.Get("ca").(type) {
+				case string:
+					cfg.CACertPaths = []string{ca}
+				case []interface{}:
+					for _, c := range ca {
+						if s, ok := c.(string); ok {
+							cfg.CACertPaths = append(cfg.CACertPaths, s)
+						}
+					}
+				}
+			}
+
+			if len(cfg.ClientCertPath) == 0 {
+				if client, ok := sub.Get("client").([]interface{}); ok && len(client) == 2 {
+					if cert, ok := client[0].(string); ok {
+						cfg.ClientCertPath = cert
+					}
+					if key, ok := client[1].(string); ok {
+						cfg.ClientKeyPath = key
+					}
+				}
+			}
+
+			if skip, ok := sub.Get("skip_verify").(bool); ok && skip {
+				cfg.InsecureSkipVerify = true
+			}
+		}
+	}
+
+	if server, ok := tree.Get("server").(string); ok && len(server) > 0 {
+		cfg.Mirrors = append(cfg.Mirrors, server)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,125 @@
+package image
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadDockerCertsDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imgpkg-certs-d")
+	if err != nil {
+		t.Fatalf("Creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	hostDir := filepath.Join(dir, "registry.example.com:5000")
+	err = os.MkdirAll(hostDir, 0700)
+	if err != nil {
+		t.Fatalf("Creating host dir: %s", err)
+	}
+
+	writeFile := func(name, contents string) {
+		err := ioutil.WriteFile(filepath.Join(hostDir, name), []byte(contents), 0600)
+		if err != nil {
+			t.Fatalf("Writing '%s': %s", name, err)
+		}
+	}
+	writeFile("ca.crt", "ca-data")
+	writeFile("client.cert", "cert-data")
+	writeFile("client.key", "key-data")
+
+	configs, err := LoadDockerCertsDir(dir)
+	if err != nil {
+		t.Fatalf("LoadDockerCertsDir: %s", err)
+	}
+
+	cfg, found := configs["registry.example.com:5000"]
+	if !found {
+		t.Fatalf("Expected config for 'registry.example.com:5000', got %#v", configs)
+	}
+
+	if len(cfg.CACertPaths) != 1 || cfg.CACertPaths[0] != filepath.Join(hostDir, "ca.crt") {
+		t.Fatalf("Unexpected CACertPaths: %#v", cfg.CACertPaths)
+	}
+	if cfg.ClientCertPath != filepath.Join(hostDir, "client.cert") {
+		t.Fatalf("Unexpected ClientCertPath: %s", cfg.ClientCertPath)
+	}
+	if cfg.ClientKeyPath != filepath.Join(hostDir, "client.key") {
+		t.Fatalf("Unexpected ClientKeyPath: %s", cfg.ClientKeyPath)
+	}
+}
+
+func TestLoadContainerdHostsTOML(t *testing.T) {
+	cases := []struct {
+		name        string
+		toml        string
+		wantMirrors []string
+		wantCA      []string
+		wantSkip    bool
+	}{
+		{
+			name: "mirrors in file order, server as fallback",
+			toml: `server = "https://registry.example.com"
+
+[host."https://mirror-1.internal"]
+  ca = "/etc/certs/mirror-1.crt"
+
+[host."https://mirror-2.internal"]
+  skip_verify = true
+`,
+			wantMirrors: []string{"https://mirror-1.internal", "https://mirror-2.internal", "https://registry.example.com"},
+			wantCA:      []string{"/etc/certs/mirror-1.crt"},
+			wantSkip:    true,
+		},
+		{
+			name:        "no host table, server only",
+			toml:        `server = "https://registry.example.com"`,
+			wantMirrors: []string{"https://registry.example.com"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "imgpkg-hosts-toml")
+			if err != nil {
+				t.Fatalf("Creating temp dir: %s", err)
+			}
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "hosts.toml")
+			err = ioutil.WriteFile(path, []byte(c.toml), 0600)
+			if err != nil {
+				t.Fatalf("Writing hosts.toml: %s", err)
+			}
+
+			cfg, err := LoadContainerdHostsTOML("registry.example.com", path)
+			if err != nil {
+				t.Fatalf("LoadContainerdHostsTOML: %s", err)
+			}
+
+			if len(cfg.Mirrors) != len(c.wantMirrors) {
+				t.Fatalf("Mirrors = %#v, want %#v", cfg.Mirrors, c.wantMirrors)
+			}
+			for i := range c.wantMirrors {
+				if cfg.Mirrors[i] != c.wantMirrors[i] {
+					t.Fatalf("Mirrors = %#v, want %#v", cfg.Mirrors, c.wantMirrors)
+				}
+			}
+
+			if len(cfg.CACertPaths) != len(c.wantCA) {
+				t.Fatalf("CACertPaths = %#v, want %#v", cfg.CACertPaths, c.wantCA)
+			}
+			for i := range c.wantCA {
+				if cfg.CACertPaths[i] != c.wantCA[i] {
+					t.Fatalf("CACertPaths = %#v, want %#v", cfg.CACertPaths, c.wantCA)
+				}
+			}
+
+			if cfg.InsecureSkipVerify != c.wantSkip {
+				t.Fatalf("InsecureSkipVerify = %v, want %v", cfg.InsecureSkipVerify, c.wantSkip)
+			}
+		})
+	}
+}
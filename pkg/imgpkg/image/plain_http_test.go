@@ -0,0 +1,63 @@
+package image
+
+import (
+	"testing"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestMaybePlainHTTP(t *testing.T) {
+	cases := []struct {
+		name         string
+		opts         RegistryOpts
+		ref          string
+		wantInsecure bool
+	}{
+		{
+			name:         "PlainHTTP forces every registry",
+			opts:         RegistryOpts{PlainHTTP: true},
+			ref:          "example.com/repo:tag",
+			wantInsecure: true,
+		},
+		{
+			name:         "PlainHTTPRegistries matches listed host",
+			opts:         RegistryOpts{PlainHTTPRegistries: []string{"localhost:5000"}},
+			ref:          "localhost:5000/repo:tag",
+			wantInsecure: true,
+		},
+		{
+			name:         "PlainHTTPRegistries does not match other hosts",
+			opts:         RegistryOpts{PlainHTTPRegistries: []string{"localhost:5000"}},
+			ref:          "example.com/repo:tag",
+			wantInsecure: false,
+		},
+		{
+			name:         "no plain http configured",
+			opts:         RegistryOpts{},
+			ref:          "example.com/repo:tag",
+			wantInsecure: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ref, err := regname.ParseReference(c.ref)
+			if err != nil {
+				t.Fatalf("Parsing ref '%s': %s", c.ref, err)
+			}
+
+			reg := Registry{c.opts}
+
+			rewritten, err := reg.maybePlainHTTP(ref)
+			if err != nil {
+				t.Fatalf("maybePlainHTTP: %s", err)
+			}
+
+			gotInsecure := rewritten.Context().Registry.Scheme() == "http"
+			if gotInsecure != c.wantInsecure {
+				t.Fatalf("maybePlainHTTP(%s) scheme = %s, want insecure = %v",
+					c.ref, rewritten.Context().Registry.Scheme(), c.wantInsecure)
+			}
+		})
+	}
+}
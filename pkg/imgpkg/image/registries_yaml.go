@@ -0,0 +1,81 @@
+package image
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	regyaml "gopkg.in/yaml.v2"
+)
+
+// RegistriesYAMLAuth mirrors the `auth` block of a rancher/k3s-style
+// registries.yaml entry.
+type RegistriesYAMLAuth struct {
+	Username      string `yaml:"username"`
+	Password      string `yaml:"password"`
+	Auth          string `yaml:"auth"`
+	IdentityToken string `yaml:"identitytoken"`
+}
+
+// RegistriesYAMLTLS mirrors the `tls` block of a registries.yaml entry.
+type RegistriesYAMLTLS struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// RegistriesYAMLMirror mirrors a single `mirrors.<host>` entry.
+type RegistriesYAMLMirror struct {
+	Endpoint []string `yaml:"endpoint"`
+}
+
+// RegistriesYAML is the root of a rancher/k3s-style registries.yaml file,
+// mapping registry hostnames to endpoint mirrors, TLS settings and auth.
+type RegistriesYAML struct {
+	Mirrors map[string]RegistriesYAMLMirror `yaml:"mirrors"`
+	Configs map[string]struct {
+		TLS  RegistriesYAMLTLS  `yaml:"tls"`
+		Auth RegistriesYAMLAuth `yaml:"auth"`
+	} `yaml:"configs"`
+}
+
+// LoadRegistriesYAML reads a registries.yaml file and returns a
+// HostConfigs built from its `configs` and `mirrors` sections. Auth
+// credentials are not part of HostConfig (they are resolved via the
+// registry keychain instead) and are returned separately.
+func LoadRegistriesYAML(path string) (HostConfigs, map[string]RegistriesYAMLAuth, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Reading registries.yaml '%s': %s", path, err)
+	}
+
+	var parsed RegistriesYAML
+	err = regyaml.Unmarshal(raw, &parsed)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Parsing registries.yaml '%s': %s", path, err)
+	}
+
+	configs := HostConfigs{}
+	auths := map[string]RegistriesYAMLAuth{}
+
+	for host, c := range parsed.Configs {
+		cfg := HostConfig{
+			InsecureSkipVerify: c.TLS.InsecureSkipVerify,
+			ClientCertPath:     c.TLS.CertFile,
+			ClientKeyPath:      c.TLS.KeyFile,
+		}
+		if len(c.TLS.CAFile) > 0 {
+			cfg.CACertPaths = []string{c.TLS.CAFile}
+		}
+		configs[host] = cfg
+		auths[host] = c.Auth
+	}
+
+	for host, m := range parsed.Mirrors {
+		cfg := configs[host]
+		cfg.Mirrors = append(cfg.Mirrors, m.Endpoint...)
+		configs[host] = cfg
+	}
+
+	return configs, auths, nil
+}
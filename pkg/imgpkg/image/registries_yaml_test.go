@@ -0,0 +1,81 @@
+package image
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRegistriesYAML(t *testing.T) {
+	contents := `
+mirrors:
+  registry.example.com:
+    endpoint:
+      - "https://mirror-1.internal"
+      - "https://mirror-2.internal"
+configs:
+  registry.example.com:
+    tls:
+      ca_file: /etc/certs/ca.crt
+      cert_file: /etc/certs/client.cert
+      key_file: /etc/certs/client.key
+      insecure_skip_verify: true
+    auth:
+      username: user
+      password: pass
+`
+
+	dir, err := ioutil.TempDir("", "imgpkg-registries-yaml")
+	if err != nil {
+		t.Fatalf("Creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "registries.yaml")
+	err = ioutil.WriteFile(path, []byte(contents), 0600)
+	if err != nil {
+		t.Fatalf("Writing registries.yaml: %s", err)
+	}
+
+	configs, auths, err := LoadRegistriesYAML(path)
+	if err != nil {
+		t.Fatalf("LoadRegistriesYAML: %s", err)
+	}
+
+	cfg, found := configs["registry.example.com"]
+	if !found {
+		t.Fatalf("Expected config for 'registry.example.com', got %#v", configs)
+	}
+
+	wantMirrors := []string{"https://mirror-1.internal", "https://mirror-2.internal"}
+	if len(cfg.Mirrors) != len(wantMirrors) {
+		t.Fatalf("Mirrors = %#v, want %#v", cfg.Mirrors, wantMirrors)
+	}
+	for i := range wantMirrors {
+		if cfg.Mirrors[i] != wantMirrors[i] {
+			t.Fatalf("Mirrors = %#v, want %#v", cfg.Mirrors, wantMirrors)
+		}
+	}
+
+	if len(cfg.CACertPaths) != 1 || cfg.CACertPaths[0] != "/etc/certs/ca.crt" {
+		t.Fatalf("Unexpected CACertPaths: %#v", cfg.CACertPaths)
+	}
+	if cfg.ClientCertPath != "/etc/certs/client.cert" {
+		t.Fatalf("Unexpected ClientCertPath: %s", cfg.ClientCertPath)
+	}
+	if cfg.ClientKeyPath != "/etc/certs/client.key" {
+		t.Fatalf("Unexpected ClientKeyPath: %s", cfg.ClientKeyPath)
+	}
+	if !cfg.InsecureSkipVerify {
+		t.Fatalf("Expected InsecureSkipVerify to be true")
+	}
+
+	auth, found := auths["registry.example.com"]
+	if !found {
+		t.Fatalf("Expected auth for 'registry.example.com', got %#v", auths)
+	}
+	if auth.Username != "user" || auth.Password != "pass" {
+		t.Fatalf("Unexpected auth: %#v", auth)
+	}
+}
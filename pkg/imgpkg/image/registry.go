@@ -3,15 +3,22 @@ package image
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"time"
 
 	regauthn "github.com/google/go-containerregistry/pkg/authn"
 	regname "github.com/google/go-containerregistry/pkg/name"
 	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	regempty "github.com/google/go-containerregistry/pkg/v1/empty"
+	regmutate "github.com/google/go-containerregistry/pkg/v1/mutate"
 	regremote "github.com/google/go-containerregistry/pkg/v1/remote"
 	regremtran "github.com/google/go-containerregistry/pkg/v1/remote/transport"
 )
@@ -20,6 +27,38 @@ type RegistryOpts struct {
 	CACertPaths []string
 	VerifyCerts bool
 
+	// ClientCertPath/ClientKeyPath (file paths) or ClientCert/ClientKey
+	// (raw PEM bytes) configure a client certificate to present for mTLS.
+	// At most one of the two forms should be set; ClientCertPath takes
+	// precedence if both are.
+	//
+	// This package doesn't own a CLI layer, so wiring these into flags is
+	// the responsibility of whatever imgpkg command constructs
+	// RegistryOpts; it should add flags that populate these fields.
+	ClientCertPath string
+	ClientKeyPath  string
+	ClientCert     string
+	ClientKey      string
+
+	// DockerCertsDir and ContainerdHostsDir point at directories laid out
+	// like Docker's /etc/docker/certs.d and containerd's
+	// /etc/containerd/certs.d respectively, providing per-registry TLS
+	// and endpoint mirror configuration without CLI flags.
+	DockerCertsDir     string
+	ContainerdHostsDir string
+	RegistriesYAMLPath string
+
+	// PlainHTTP forces plain http:// (no TLS) for every registry, and
+	// PlainHTTPRegistries does the same for just the listed hostnames
+	// (e.g. "localhost:5000"). Useful for local dev registries and
+	// internal HTTP-only mirrors.
+	PlainHTTP           bool
+	PlainHTTPRegistries []string
+
+	// Retry controls how failed requests are retried. The zero value
+	// falls back to DefaultRetryOpts().
+	Retry RetryOpts
+
 	Username string
 	Password string
 	Token    string
@@ -30,17 +69,55 @@ type Registry struct {
 	opts RegistryOpts
 }
 
-func NewRegistry(opts RegistryOpts) Registry {
-	return Registry{opts}
+// NewRegistry validates opts (in particular, that any configured client
+// certificate/key pair actually loads) before returning a Registry, so
+// that a bad configuration is reported immediately rather than on the
+// first pull or push.
+func NewRegistry(opts RegistryOpts) (Registry, error) {
+	err := opts.validate()
+	if err != nil {
+		return Registry{}, err
+	}
+	return Registry{opts}, nil
+}
+
+func (o RegistryOpts) validate() error {
+	switch {
+	case len(o.ClientCertPath) > 0 || len(o.ClientKeyPath) > 0:
+		_, err := tls.LoadX509KeyPair(o.ClientCertPath, o.ClientKeyPath)
+		if err != nil {
+			return fmt.Errorf("Loading client certificate/key pair from '%s'/'%s': %s",
+				o.ClientCertPath, o.ClientKeyPath, err)
+		}
+
+	case len(o.ClientCert) > 0 || len(o.ClientKey) > 0:
+		_, err := tls.X509KeyPair([]byte(o.ClientCert), []byte(o.ClientKey))
+		if err != nil {
+			return fmt.Errorf("Loading client certificate/key pair: %s", err)
+		}
+	}
+
+	return nil
 }
 
 func (i Registry) Generic(ref regname.Reference) (regv1.Descriptor, error) {
-	opts, err := i.imageOpts()
+	ref, err := i.maybePlainHTTP(ref)
 	if err != nil {
 		return regv1.Descriptor{}, err
 	}
 
-	desc, err := regremote.Get(ref, opts...)
+	tran, opts, err := i.imageOpts()
+	if err != nil {
+		return regv1.Descriptor{}, err
+	}
+
+	var desc *regremote.Descriptor
+
+	err = i.retry(tran, func() error {
+		var err2 error
+		desc, err2 = regremote.Get(ref, opts...)
+		return err2
+	})
 	if err != nil {
 		return regv1.Descriptor{}, err
 	}
@@ -49,16 +126,103 @@ func (i Registry) Generic(ref regname.Reference) (regv1.Descriptor, error) {
 }
 
 func (i Registry) Image(ref regname.Reference) (regv1.Image, error) {
-	opts, err := i.imageOpts()
+	ref, err := i.maybePlainHTTP(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	tran, opts, err := i.imageOpts()
 	if err != nil {
 		return nil, err
 	}
 
-	return regremote.Image(ref, opts...)
+	var img regv1.Image
+
+	err = i.retry(tran, func() error {
+		var err2 error
+		img, err2 = regremote.Image(ref, opts...)
+		return err2
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return img, nil
 }
 
 func (i Registry) WriteImage(ref regname.Reference, img regv1.Image) error {
-	httpTran, err := i.newHTTPTransport()
+	return i.writeImage(ref, img)
+}
+
+// CopyImage copies img from srcRef to dstRef. When both references share a
+// registry, each layer is wrapped as a regremote.MountableLayer pointing
+// back at srcRef, so the registry mounts it from srcRef's repository
+// instead of imgpkg re-uploading it. The blob-mount API already falls
+// back to a normal upload per-layer when the registry rejects (or doesn't
+// support) the mount, so no separate whole-image retry is needed here.
+func (i Registry) CopyImage(srcRef regname.Reference, dstRef regname.Reference) error {
+	srcRef, err := i.maybePlainHTTP(srcRef)
+	if err != nil {
+		return err
+	}
+
+	img, err := i.Image(srcRef)
+	if err != nil {
+		return fmt.Errorf("Getting image '%s': %s", srcRef, err)
+	}
+
+	if srcRef.Context().RegistryStr() == dstRef.Context().RegistryStr() {
+		img, err = mountableImage(img, srcRef)
+		if err != nil {
+			return fmt.Errorf("Preparing image '%s' for cross-repo mount: %s", srcRef, err)
+		}
+	}
+
+	return i.writeImage(dstRef, img)
+}
+
+// mountableImage rebuilds img, keeping its config but wrapping each layer
+// as a regremote.MountableLayer referencing src, so that remote.Write
+// mounts them from src's repository rather than uploading their content.
+func mountableImage(img regv1.Image, src regname.Reference) (regv1.Image, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("Getting image layers: %s", err)
+	}
+
+	cfgFile, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("Getting image config: %s", err)
+	}
+
+	mediaType, err := img.MediaType()
+	if err != nil {
+		return nil, fmt.Errorf("Getting image media type: %s", err)
+	}
+
+	mountable := regempty.Image
+	mountable = regmutate.MediaType(mountable, mediaType)
+
+	mountable, err = regmutate.ConfigFile(mountable, cfgFile)
+	if err != nil {
+		return nil, fmt.Errorf("Setting image config: %s", err)
+	}
+
+	mountableLayers := make([]regv1.Layer, len(layers))
+	for idx, l := range layers {
+		mountableLayers[idx] = &regremote.MountableLayer{Layer: l, Reference: src}
+	}
+
+	return regmutate.AppendLayers(mountable, mountableLayers...)
+}
+
+func (i Registry) writeImage(ref regname.Reference, img regv1.Image) error {
+	ref, err := i.maybePlainHTTP(ref)
+	if err != nil {
+		return err
+	}
+
+	httpTran, err := i.newRoundTripper()
 	if err != nil {
 		return err
 	}
@@ -68,7 +232,7 @@ func (i Registry) WriteImage(ref regname.Reference, img regv1.Image) error {
 		return fmt.Errorf("Getting auth details: %s", err)
 	}
 
-	err = i.retry(func() error {
+	err = i.retry(httpTran, func() error {
 		return regremote.Write(ref, img, regremote.WithAuth(auth), regremote.WithTransport(httpTran))
 	})
 	if err != nil {
@@ -78,17 +242,98 @@ func (i Registry) WriteImage(ref regname.Reference, img regv1.Image) error {
 	return nil
 }
 
+// Token resolves the Authorization header value (e.g. "Bearer <token>")
+// for repo by running the registry's ping/challenge flow, scoped for push
+// when push is true and pull otherwise, plus any additional scopes (e.g. a
+// pull scope on a second repository, to pre-authorize a cross-repo mount).
+// This lets callers pipe imgpkg-resolved credentials into tools like curl
+// for registry endpoints this package doesn't itself expose.
+func (i Registry) Token(repo regname.Repository, scopes []string, push bool) (string, error) {
+	httpTran, err := i.newRoundTripper()
+	if err != nil {
+		return "", err
+	}
+
+	auth, err := i.registryKeychain().Resolve(repo.Registry)
+	if err != nil {
+		return "", fmt.Errorf("Getting auth details: %s", err)
+	}
+
+	action := regremtran.PullScope
+	if push {
+		action = regremtran.PushScope
+	}
+	allScopes := append([]string{repo.Scope(action)}, scopes...)
+
+	capture := &authCapturingTransport{base: httpTran}
+
+	authedTran, err := regremtran.New(repo.Registry, auth, capture, allScopes)
+	if err != nil {
+		return "", fmt.Errorf("Negotiating auth for '%s': %s", repo, err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s://%s/v2/", repo.Registry.Scheme(), repo.Registry.Name()), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := authedTran.RoundTrip(req)
+	if err != nil {
+		return "", fmt.Errorf("Pinging registry '%s': %s", repo.Registry, err)
+	}
+	defer resp.Body.Close()
+
+	if len(capture.authHeader) == 0 {
+		return "", fmt.Errorf("Registry '%s' did not return an Authorization header", repo.Registry)
+	}
+
+	return capture.authHeader, nil
+}
+
+// authCapturingTransport records the Authorization header the wrapped
+// transport negotiated, so Token can hand it back to the caller.
+type authCapturingTransport struct {
+	base       http.RoundTripper
+	authHeader string
+}
+
+func (t *authCapturingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.authHeader = req.Header.Get("Authorization")
+	return t.base.RoundTrip(req)
+}
+
 func (i Registry) Index(ref regname.Reference) (regv1.ImageIndex, error) {
-	opts, err := i.imageOpts()
+	ref, err := i.maybePlainHTTP(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	tran, opts, err := i.imageOpts()
+	if err != nil {
+		return nil, err
+	}
+
+	var idx regv1.ImageIndex
+
+	err = i.retry(tran, func() error {
+		var err2 error
+		idx, err2 = regremote.Index(ref, opts...)
+		return err2
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return regremote.Index(ref, opts...)
+	return idx, nil
 }
 
 func (i Registry) WriteIndex(ref regname.Reference, idx regv1.ImageIndex) error {
-	httpTran, err := i.newHTTPTransport()
+	ref, err := i.maybePlainHTTP(ref)
+	if err != nil {
+		return err
+	}
+
+	httpTran, err := i.newRoundTripper()
 	if err != nil {
 		return err
 	}
@@ -98,7 +343,7 @@ func (i Registry) WriteIndex(ref regname.Reference, idx regv1.ImageIndex) error
 		return fmt.Errorf("Getting auth details: %s", err)
 	}
 
-	err = i.retry(func() error {
+	err = i.retry(httpTran, func() error {
 		return regremote.WriteIndex(ref, idx, regremote.WithAuth(auth), regremote.WithTransport(httpTran))
 	})
 	if err != nil {
@@ -109,7 +354,12 @@ func (i Registry) WriteIndex(ref regname.Reference, idx regv1.ImageIndex) error
 }
 
 func (i Registry) ListTags(repo regname.Repository) ([]string, error) {
-	httpTran, err := i.newHTTPTransport()
+	repo, err := i.maybePlainHTTPRepo(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	httpTran, err := i.newRoundTripper()
 	if err != nil {
 		return nil, err
 	}
@@ -119,16 +369,27 @@ func (i Registry) ListTags(repo regname.Repository) ([]string, error) {
 		return nil, fmt.Errorf("Getting auth details: %s", err)
 	}
 
-	return regremote.List(repo, regremote.WithAuth(auth), regremote.WithTransport(httpTran))
-}
+	var tags []string
 
-func (i Registry) imageOpts() ([]regremote.Option, error) {
-	httpTran, err := i.newHTTPTransport()
+	err = i.retry(httpTran, func() error {
+		var err2 error
+		tags, err2 = regremote.List(repo, regremote.WithAuth(auth), regremote.WithTransport(httpTran))
+		return err2
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return []regremote.Option{
+	return tags, nil
+}
+
+func (i Registry) imageOpts() (*retryTrackingTransport, []regremote.Option, error) {
+	httpTran, err := i.newRoundTripper()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return httpTran, []regremote.Option{
 		regremote.WithTransport(httpTran),
 		regremote.WithAuthFromKeychain(i.registryKeychain()),
 	}, nil
@@ -138,6 +399,43 @@ func (i Registry) registryKeychain() regauthn.Keychain {
 	return customRegistryKeychain{i.opts}
 }
 
+// maybePlainHTTP rewrites ref to target an insecure (http://) registry
+// when PlainHTTP is set or ref's registry is in PlainHTTPRegistries,
+// preserving the tag/digest.
+func (i Registry) maybePlainHTTP(ref regname.Reference) (regname.Reference, error) {
+	if !i.plainHTTP(ref.Context().RegistryStr()) {
+		return ref, nil
+	}
+
+	switch v := ref.(type) {
+	case regname.Tag:
+		return regname.NewTag(v.String(), regname.WeakValidation, regname.Insecure)
+	case regname.Digest:
+		return regname.NewDigest(v.String(), regname.WeakValidation, regname.Insecure)
+	default:
+		return ref, nil
+	}
+}
+
+func (i Registry) maybePlainHTTPRepo(repo regname.Repository) (regname.Repository, error) {
+	if !i.plainHTTP(repo.RegistryStr()) {
+		return repo, nil
+	}
+	return regname.NewRepository(repo.Name(), regname.WeakValidation, regname.Insecure)
+}
+
+func (i Registry) plainHTTP(registry string) bool {
+	if i.opts.PlainHTTP {
+		return true
+	}
+	for _, r := range i.opts.PlainHTTPRegistries {
+		if r == registry {
+			return true
+		}
+	}
+	return false
+}
+
 func (i Registry) newHTTPTransport() (*http.Transport, error) {
 	pool, err := x509.SystemCertPool()
 	if err != nil {
@@ -154,6 +452,25 @@ func (i Registry) newHTTPTransport() (*http.Transport, error) {
 		}
 	}
 
+	var clientCerts []tls.Certificate
+
+	switch {
+	case len(i.opts.ClientCertPath) > 0 || len(i.opts.ClientKeyPath) > 0:
+		cert, err := tls.LoadX509KeyPair(i.opts.ClientCertPath, i.opts.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("Loading client certificate/key pair from '%s'/'%s': %s",
+				i.opts.ClientCertPath, i.opts.ClientKeyPath, err)
+		}
+		clientCerts = append(clientCerts, cert)
+
+	case len(i.opts.ClientCert) > 0 || len(i.opts.ClientKey) > 0:
+		cert, err := tls.X509KeyPair([]byte(i.opts.ClientCert), []byte(i.opts.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("Loading client certificate/key pair: %s", err)
+		}
+		clientCerts = append(clientCerts, cert)
+	}
+
 	// Copied from https://github.com/golang/go/blob/release-branch.go1.12/src/net/http/transport.go#L42-L53
 	// We want to use the DefaultTransport but change its TLSClientConfig. There
 	// isn't a clean way to do this yet: https://github.com/golang/go/issues/26013
@@ -172,31 +489,268 @@ func (i Registry) newHTTPTransport() (*http.Transport, error) {
 		// Use the cert pool with k8s cert bundle appended.
 		TLSClientConfig: &tls.Config{
 			RootCAs:            pool,
+			Certificates:       clientCerts,
 			InsecureSkipVerify: (i.opts.VerifyCerts == false),
 		},
 	}, nil
 }
 
-func (i Registry) retry(doFunc func() error) error {
+// newRoundTripper returns the transport requests should be made with. When
+// DockerCertsDir, ContainerdHostsDir or RegistriesYAMLPath are configured,
+// the underlying transport is host-aware, applying per-registry TLS
+// settings and endpoint mirrors; otherwise the default transport is used
+// unchanged. Either way the result tracks the Retry-After header of the
+// last response it saw, for retry to consult.
+func (i Registry) newRoundTripper() (*retryTrackingTransport, error) {
+	httpTran, err := i.newHTTPTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	hostConfigs, err := i.loadHostConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	var base http.RoundTripper = httpTran
+	if len(hostConfigs) > 0 {
+		base, err = newHostAwareTransport(httpTran, httpTran.TLSClientConfig, hostConfigs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &retryTrackingTransport{base: base}, nil
+}
+
+func (i Registry) loadHostConfigs() (HostConfigs, error) {
+	merged := HostConfigs{}
+
+	mergeHost := func(host string, cfg HostConfig) {
+		existing := merged[host]
+
+		if len(cfg.CACertPaths) > 0 {
+			existing.CACertPaths = cfg.CACertPaths
+		}
+		if len(cfg.ClientCertPath) > 0 {
+			existing.ClientCertPath = cfg.ClientCertPath
+		}
+		if len(cfg.ClientKeyPath) > 0 {
+			existing.ClientKeyPath = cfg.ClientKeyPath
+		}
+		if cfg.InsecureSkipVerify {
+			existing.InsecureSkipVerify = true
+		}
+		if len(cfg.Mirrors) > 0 {
+			existing.Mirrors = cfg.Mirrors
+		}
+
+		merged[host] = existing
+	}
+
+	mergeAll := func(configs HostConfigs) {
+		for host, cfg := range configs {
+			mergeHost(host, cfg)
+		}
+	}
+
+	if len(i.opts.DockerCertsDir) > 0 {
+		configs, err := LoadDockerCertsDir(i.opts.DockerCertsDir)
+		if err != nil {
+			return nil, err
+		}
+		mergeAll(configs)
+	}
+
+	if len(i.opts.ContainerdHostsDir) > 0 {
+		entries, err := ioutil.ReadDir(i.opts.ContainerdHostsDir)
+		if err != nil {
+			return nil, fmt.Errorf("Reading containerd hosts directory '%s': %s", i.opts.ContainerdHostsDir, err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			host := entry.Name()
+			path := filepath.Join(i.opts.ContainerdHostsDir, host, "hosts.toml")
+			cfg, err := LoadContainerdHostsTOML(host, path)
+			if err != nil {
+				return nil, err
+			}
+			mergeHost(host, cfg)
+		}
+	}
+
+	if len(i.opts.RegistriesYAMLPath) > 0 {
+		configs, _, err := LoadRegistriesYAML(i.opts.RegistriesYAMLPath)
+		if err != nil {
+			return nil, err
+		}
+		mergeAll(configs)
+	}
+
+	return merged, nil
+}
+
+// RetryOpts configures Registry's retry policy. The zero value is not
+// usable directly; Registry falls back to DefaultRetryOpts() when
+// MaxAttempts is unset.
+type RetryOpts struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	Jitter         bool
+}
+
+// DefaultRetryOpts returns the retry policy Registry uses when none is
+// configured: 5 attempts, starting at a 1 second backoff, doubling up to
+// 30 seconds, with jitter.
+func DefaultRetryOpts() RetryOpts {
+	return RetryOpts{
+		MaxAttempts:    5,
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         true,
+	}
+}
+
+func (i Registry) retry(tran *retryTrackingTransport, doFunc func() error) error {
+	opts := i.opts.Retry
+	if opts.MaxAttempts == 0 {
+		opts = DefaultRetryOpts()
+	}
+
+	backoff := opts.InitialBackoff
 	var lastErr error
 
-	for i := 0; i < 5; i++ {
+	for attempt := 0; attempt < opts.MaxAttempts; attempt++ {
+		if tran != nil {
+			tran.ResetRetryAfter()
+		}
+
 		lastErr = doFunc()
 		if lastErr == nil {
 			return nil
 		}
 
-		if tranErr, ok := lastErr.(*regremtran.Error); ok {
-			if len(tranErr.Errors) > 0 {
-				if tranErr.Errors[0].Code == regremtran.UnauthorizedErrorCode {
-					return fmt.Errorf("Non-retryable error: %s", lastErr)
-				}
+		if !isRetryableErr(lastErr) {
+			return fmt.Errorf("Non-retryable error: %w", lastErr)
+		}
+
+		if attempt == opts.MaxAttempts-1 {
+			break
+		}
+
+		sleep := backoff
+		if opts.Jitter {
+			sleep = jitterDuration(sleep)
+		}
+		if tran != nil {
+			if retryAfter := tran.RetryAfter(); retryAfter > sleep {
+				sleep = retryAfter
 			}
 		}
 
-		time.Sleep(1 * time.Second)
+		time.Sleep(sleep)
+
+		backoff = time.Duration(float64(backoff) * opts.Multiplier)
+		if opts.MaxBackoff > 0 && backoff > opts.MaxBackoff {
+			backoff = opts.MaxBackoff
+		}
+	}
+	return fmt.Errorf("Retried %d times: %w", opts.MaxAttempts, lastErr)
+}
+
+// isRetryableErr decides whether a failed request is worth retrying.
+// Client errors (400/401/403/404) are never retried since a retry cannot
+// fix a bad request, missing auth or a nonexistent resource; rate limits
+// and server errors (429/5xx) always are. Anything else (e.g. a network
+// error that never reached the registry) is retried, matching the
+// permissive behavior this package has always had.
+func isRetryableErr(err error) bool {
+	var tranErr *regremtran.Error
+	if !errors.As(err, &tranErr) {
+		return true
 	}
-	return fmt.Errorf("Retried 5 times: %s", lastErr)
+
+	switch tranErr.StatusCode {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return false
+	}
+
+	return true
+}
+
+func jitterDuration(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// retryTrackingTransport wraps another transport and remembers the largest
+// Retry-After value (if any) seen across the requests in flight for the
+// current attempt, so retry can honor it even though the underlying
+// library error type does not carry response headers. regremote.Write
+// issues concurrent requests (one per layer), so access is guarded by a
+// mutex.
+type retryTrackingTransport struct {
+	base http.RoundTripper
+
+	mu             sync.Mutex
+	lastRetryAfter time.Duration
+}
+
+func (t *retryTrackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			t.mu.Lock()
+			if d > t.lastRetryAfter {
+				t.lastRetryAfter = d
+			}
+			t.mu.Unlock()
+		}
+	}
+
+	return resp, err
+}
+
+// RetryAfter returns the Retry-After duration observed since the last
+// call to ResetRetryAfter (or since creation), or 0 if none was seen.
+func (t *retryTrackingTransport) RetryAfter() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lastRetryAfter
+}
+
+// ResetRetryAfter clears the observed Retry-After so a prior attempt's
+// value isn't mistakenly applied to the next one.
+func (t *retryTrackingTransport) ResetRetryAfter() {
+	t.mu.Lock()
+	t.lastRetryAfter = 0
+	t.mu.Unlock()
+}
+
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if len(v) == 0 {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+	}
+
+	return 0, false
 }
 
 type customRegistryKeychain struct {
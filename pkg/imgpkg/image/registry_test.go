@@ -0,0 +1,84 @@
+package image
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	regremtran "github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	future := time.Now().Add(5 * time.Second).UTC().Format(http.TimeFormat)
+	past := time.Now().Add(-5 * time.Second).UTC().Format(http.TimeFormat)
+
+	cases := []struct {
+		name        string
+		header      string
+		wantOK      bool
+		wantAtLeast time.Duration
+	}{
+		{name: "empty", header: "", wantOK: false},
+		{name: "seconds", header: "30", wantOK: true, wantAtLeast: 30 * time.Second},
+		{name: "http date in future", header: future, wantOK: true, wantAtLeast: 1 * time.Second},
+		{name: "http date in past", header: past, wantOK: false},
+		{name: "garbage", header: "not-a-duration", wantOK: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(c.header)
+			if ok != c.wantOK {
+				t.Fatalf("parseRetryAfter(%q) ok = %v, want %v", c.header, ok, c.wantOK)
+			}
+			if ok && got < c.wantAtLeast {
+				t.Fatalf("parseRetryAfter(%q) = %s, want at least %s", c.header, got, c.wantAtLeast)
+			}
+		})
+	}
+}
+
+func TestIsRetryableErr(t *testing.T) {
+	transportErr := func(status int) error {
+		return &regremtran.Error{StatusCode: status}
+	}
+
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "400 is not retryable", err: transportErr(http.StatusBadRequest), want: false},
+		{name: "401 is not retryable", err: transportErr(http.StatusUnauthorized), want: false},
+		{name: "403 is not retryable", err: transportErr(http.StatusForbidden), want: false},
+		{name: "404 is not retryable", err: transportErr(http.StatusNotFound), want: false},
+		{name: "429 is retryable", err: transportErr(http.StatusTooManyRequests), want: true},
+		{name: "500 is retryable", err: transportErr(http.StatusInternalServerError), want: true},
+		{name: "503 is retryable", err: transportErr(http.StatusServiceUnavailable), want: true},
+		{name: "non-transport error is retryable", err: fmt.Errorf("dial tcp: connection refused"), want: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isRetryableErr(c.err); got != c.want {
+				t.Fatalf("isRetryableErr(%v) = %v, want %v", c.err, got, c.want)
+			}
+		})
+	}
+}
+
+func TestJitterDuration(t *testing.T) {
+	d := 10 * time.Second
+
+	for i := 0; i < 100; i++ {
+		got := jitterDuration(d)
+		if got < d/2 || got > d {
+			t.Fatalf("jitterDuration(%s) = %s, want value in [%s, %s]", d, got, d/2, d)
+		}
+	}
+
+	if got := jitterDuration(0); got != 0 {
+		t.Fatalf("jitterDuration(0) = %s, want 0", got)
+	}
+}